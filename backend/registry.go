@@ -0,0 +1,47 @@
+package backend
+
+import "fmt"
+
+// OpenFunc opens an existing backend for the given URI.
+type OpenFunc func(url string) (Backend, error)
+
+// CreateFunc creates a new backend for the given URI.
+type CreateFunc func(url string) (Backend, error)
+
+type registryEntry struct {
+	open   OpenFunc
+	create CreateFunc
+}
+
+var registry = make(map[string]registryEntry)
+
+// Register makes a backend available under the given URI scheme, so that
+// Open and Create can find it later. Backends are expected to call this
+// from an init() function in their own package.
+func Register(scheme string, open OpenFunc, create CreateFunc) {
+	if _, ok := registry[scheme]; ok {
+		panic(fmt.Sprintf("backend: Register called twice for scheme %q", scheme))
+	}
+
+	registry[scheme] = registryEntry{open: open, create: create}
+}
+
+// Open opens the backend registered for scheme, passing it the full URI.
+func Open(scheme, uri string) (Backend, error) {
+	entry, ok := registry[scheme]
+	if !ok {
+		return nil, fmt.Errorf("backend: no backend registered for scheme %q", scheme)
+	}
+
+	return entry.open(uri)
+}
+
+// Create creates the backend registered for scheme, passing it the full URI.
+func Create(scheme, uri string) (Backend, error) {
+	entry, ok := registry[scheme]
+	if !ok {
+		return nil, fmt.Errorf("backend: no backend registered for scheme %q", scheme)
+	}
+
+	return entry.create(uri)
+}
@@ -0,0 +1,195 @@
+// Package s3 implements a restic backend that stores data in an S3 bucket,
+// either on Amazon S3 itself or on an S3-compatible endpoint such as MinIO.
+package s3
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go"
+	"github.com/restic/restic/backend"
+	"github.com/restic/restic/debug"
+)
+
+type s3Backend struct {
+	client     *minio.Client
+	bucketName string
+	prefix     string
+}
+
+// config holds the parts of an s3: URI needed to connect to a bucket.
+type config struct {
+	endpoint string
+	secure   bool
+	bucket   string
+	prefix   string
+}
+
+// parseConfig parses URIs of the form
+// s3:https://s3.amazonaws.com/bucketname/prefix or
+// s3:http://minio.example.com:9000/bucketname/prefix. The leading "s3:"
+// is the scheme main.go dispatches on; what follows it is a regular URL
+// and must be parsed on its own, since url.Parse("s3:https://...") treats
+// everything after "s3:" as opaque instead of a host/path.
+func parseConfig(uri string) (config, error) {
+	endpoint := strings.TrimPrefix(uri, "s3:")
+	if endpoint == uri {
+		return config{}, fmt.Errorf("s3: URI %q does not start with \"s3:\"", uri)
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return config{}, err
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(parsed.Path, "/"), "/", 2)
+	if parts[0] == "" {
+		return config{}, fmt.Errorf("s3: no bucket name found in %q", uri)
+	}
+
+	cfg := config{
+		endpoint: parsed.Host,
+		secure:   parsed.Scheme == "https",
+		bucket:   parts[0],
+	}
+
+	if len(parts) > 1 {
+		cfg.prefix = parts[1]
+	}
+
+	return cfg, nil
+}
+
+func newClient(cfg config) (*minio.Client, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	return minio.New(cfg.endpoint, accessKey, secretKey, cfg.secure)
+}
+
+// Open opens an s3 backend for an existing bucket at the given URI, e.g.
+// s3:https://s3.amazonaws.com/bucketname/prefix.
+func Open(uri string) (backend.Backend, error) {
+	cfg, err := parseConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := client.BucketExists(cfg.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf("s3: bucket %s does not exist", cfg.bucket)
+	}
+
+	return &s3Backend{client: client, bucketName: cfg.bucket, prefix: cfg.prefix}, nil
+}
+
+// Create creates a new s3 backend at the given URI, creating the bucket if
+// it does not exist yet.
+func Create(uri string) (backend.Backend, error) {
+	cfg, err := parseConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := client.BucketExists(cfg.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		if err = client.MakeBucket(cfg.bucket, ""); err != nil {
+			return nil, fmt.Errorf("s3: unable to create bucket %s: %v", cfg.bucket, err)
+		}
+	}
+
+	return &s3Backend{client: client, bucketName: cfg.bucket, prefix: cfg.prefix}, nil
+}
+
+func (be *s3Backend) key(name string) string {
+	if be.prefix == "" {
+		return name
+	}
+	return be.prefix + "/" + name
+}
+
+func (be *s3Backend) Location() string {
+	return "s3:" + be.bucketName + "/" + be.prefix
+}
+
+func (be *s3Backend) Get(name string) (io.ReadCloser, error) {
+	return be.client.GetObject(be.bucketName, be.key(name))
+}
+
+func (be *s3Backend) Put(name string, rd io.Reader) error {
+	_, err := be.client.PutObject(be.bucketName, be.key(name), rd, "application/octet-stream")
+	return err
+}
+
+func (be *s3Backend) Remove(name string) error {
+	return be.client.RemoveObject(be.bucketName, be.key(name))
+}
+
+func (be *s3Backend) Test(name string) (bool, error) {
+	_, err := be.client.StatObject(be.bucketName, be.key(name))
+	if err == nil {
+		return true, nil
+	}
+
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func (be *s3Backend) List(prefix string, done <-chan struct{}) <-chan string {
+	ch := make(chan string)
+
+	go func() {
+		defer close(ch)
+
+		for obj := range be.client.ListObjects(be.bucketName, be.key(prefix), true, done) {
+			if obj.Err != nil {
+				debug.Log("s3.List", "error listing objects: %v", obj.Err)
+				return
+			}
+
+			name := strings.TrimPrefix(obj.Key, be.key(""))
+			select {
+			case ch <- name:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+func (be *s3Backend) Close() error {
+	return nil
+}
+
+func init() {
+	backend.Register("s3", Open, Create)
+}
@@ -0,0 +1,55 @@
+package s3
+
+import "testing"
+
+func TestParseConfig(t *testing.T) {
+	var tests = []struct {
+		uri      string
+		endpoint string
+		secure   bool
+		bucket   string
+		prefix   string
+	}{
+		{"s3:https://s3.amazonaws.com/bucket/prefix", "s3.amazonaws.com", true, "bucket", "prefix"},
+		{"s3:http://minio.example.com:9000/bucket", "minio.example.com:9000", false, "bucket", ""},
+		{"s3:https://s3.amazonaws.com/bucket/some/nested/prefix", "s3.amazonaws.com", true, "bucket", "some/nested/prefix"},
+	}
+
+	for _, test := range tests {
+		cfg, err := parseConfig(test.uri)
+		if err != nil {
+			t.Errorf("parseConfig(%q) returned unexpected error: %v", test.uri, err)
+			continue
+		}
+
+		if cfg.endpoint != test.endpoint {
+			t.Errorf("parseConfig(%q): endpoint = %q, want %q", test.uri, cfg.endpoint, test.endpoint)
+		}
+
+		if cfg.secure != test.secure {
+			t.Errorf("parseConfig(%q): secure = %v, want %v", test.uri, cfg.secure, test.secure)
+		}
+
+		if cfg.bucket != test.bucket {
+			t.Errorf("parseConfig(%q): bucket = %q, want %q", test.uri, cfg.bucket, test.bucket)
+		}
+
+		if cfg.prefix != test.prefix {
+			t.Errorf("parseConfig(%q): prefix = %q, want %q", test.uri, cfg.prefix, test.prefix)
+		}
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	var tests = []string{
+		"https://s3.amazonaws.com/bucket",
+		"s3:https://s3.amazonaws.com/",
+		"s3:https://s3.amazonaws.com",
+	}
+
+	for _, uri := range tests {
+		if _, err := parseConfig(uri); err == nil {
+			t.Errorf("parseConfig(%q) did not return an error", uri)
+		}
+	}
+}
@@ -1,36 +1,42 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"os/exec"
 	"runtime"
+	"strings"
 
 	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/restic/restic/backend"
 	"github.com/restic/restic/backend/local"
+	_ "github.com/restic/restic/backend/s3"
 	"github.com/restic/restic/backend/sftp"
 	"github.com/restic/restic/debug"
+	"github.com/restic/restic/output"
 	"github.com/restic/restic/repository"
 )
 
 var version = "compiled manually"
 
 var opts struct {
-	Repo string `short:"r" long:"repo"    description:"Repository directory to backup to/restore from"`
+	Repo            string `short:"r" long:"repo"             description:"Repository directory to backup to/restore from"`
+	PasswordFile    string `long:"password-file"    description:"Read the repository password from a file"`
+	PasswordCommand string `long:"password-command" description:"Read the repository password from the output of a command"`
+	PasswordStdin   bool   `long:"password-stdin"   description:"Read the repository password from stdin"`
+	JSON            bool   `long:"json"             description:"Print status and error messages as JSON"`
 }
 
 var parser = flags.NewParser(&opts, flags.Default)
 
 func errx(code int, format string, data ...interface{}) {
-	if len(format) > 0 && format[len(format)-1] != '\n' {
-		format += "\n"
-	}
-	fmt.Fprintf(os.Stderr, format, data...)
-	os.Exit(code)
+	output.Error(code, format, data...)
 }
 
 func readPassword(env string, prompt string) string {
@@ -52,85 +58,275 @@ func readPassword(env string, prompt string) string {
 	return string(pw)
 }
 
-type CmdInit struct{}
+// PasswordProvider supplies the repository password without necessarily
+// requiring an interactive terminal, so that restic can be driven from
+// scripts and other non-interactive environments.
+type PasswordProvider interface {
+	Password() (string, error)
+}
+
+// promptPasswordProvider reads the password from the given environment
+// variable, falling back to an interactive terminal prompt. This is the
+// original, interactive behavior of restic.
+type promptPasswordProvider struct {
+	env    string
+	prompt string
+}
+
+func (p promptPasswordProvider) Password() (string, error) {
+	return readPassword(p.env, p.prompt), nil
+}
+
+// filePasswordProvider reads the password from a file, as set via
+// --password-file.
+type filePasswordProvider struct {
+	path string
+}
+
+func (p filePasswordProvider) Password() (string, error) {
+	buf, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read password file %v: %v", p.path, err)
+	}
+
+	return strings.TrimRight(string(buf), "\r\n"), nil
+}
+
+// commandPasswordProvider runs a command and uses the first line of its
+// output as the password, as set via --password-command.
+type commandPasswordProvider struct {
+	cmd string
+}
+
+func (p commandPasswordProvider) Password() (string, error) {
+	out, err := exec.Command("sh", "-c", p.cmd).Output()
+	if err != nil {
+		return "", fmt.Errorf("password-command failed: %v", err)
+	}
+
+	line := strings.SplitN(string(out), "\n", 2)[0]
+	return strings.TrimRight(line, "\r"), nil
+}
+
+// stdinPasswordProvider reads a single line from stdin, as set via
+// --password-stdin.
+type stdinPasswordProvider struct{}
+
+func (p stdinPasswordProvider) Password() (string, error) {
+	sc := bufio.NewScanner(os.Stdin)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return "", err
+		}
+		return "", errors.New("unable to read password from stdin")
+	}
+
+	return sc.Text(), nil
+}
+
+// passwordProvider selects a PasswordProvider based on the non-interactive
+// flags given on the command line, falling back to env/prompt (the
+// interactive default) when none of them are set. Flags take priority over
+// the environment variable, which in turn takes priority over prompting.
+func passwordProvider(env, prompt string) PasswordProvider {
+	switch {
+	case opts.PasswordFile != "":
+		return filePasswordProvider{path: opts.PasswordFile}
+	case opts.PasswordCommand != "":
+		return commandPasswordProvider{cmd: opts.PasswordCommand}
+	case opts.PasswordStdin:
+		return stdinPasswordProvider{}
+	default:
+		return promptPasswordProvider{env: env, prompt: prompt}
+	}
+}
+
+type CmdInit struct {
+	ChunkerPolynomial             string `long:"chunker-polynomial"                description:"Set the chunker polynomial for the new repository"`
+	CopyChunkerParamsFrom         string `long:"copy-chunker-params-from"          description:"Copy the chunker polynomial from another repository, so deduplication works across both"`
+	CopyChunkerParamsPasswordFile string `long:"copy-chunker-params-password-file" description:"Read the password for --copy-chunker-params-from from a file, since it is usually a different repository"`
+	Compression                  string `long:"compression"                       default:"off" description:"Compression mode for new data (off, auto, max)"`
+}
 
 func (cmd CmdInit) Execute(args []string) error {
+	output.JSON = opts.JSON
+
 	if opts.Repo == "" {
 		return errors.New("Please specify repository location (-r)")
 	}
 
-	pw := readPassword("RESTIC_PASSWORD", "enter password for new backend: ")
-	pw2 := readPassword("RESTIC_PASSWORD", "enter password again: ")
+	provider := passwordProvider("RESTIC_PASSWORD", "enter password for new backend: ")
 
-	if pw != pw2 {
-		errx(1, "passwords do not match")
+	pw, err := provider.Password()
+	if err != nil {
+		errx(2, "unable to read password: %v", err)
+	}
+
+	// Non-interactive providers have nothing to confirm against; only ask
+	// for a second entry when the user is typing the password themselves.
+	if _, interactive := provider.(promptPasswordProvider); interactive {
+		pw2, err := (promptPasswordProvider{env: "RESTIC_PASSWORD", prompt: "enter password again: "}).Password()
+		if err != nil {
+			errx(2, "unable to read password: %v", err)
+		}
+
+		if pw != pw2 {
+			errx(1, "passwords do not match")
+		}
+	}
+
+	cfgOpts := repository.ConfigOptions{Compression: repository.CompressionMode(cmd.Compression)}
+
+	switch {
+	case cmd.CopyChunkerParamsFrom != "":
+		pol, err := copyChunkerPolynomial(cmd.CopyChunkerParamsFrom, cmd.CopyChunkerParamsPasswordFile)
+		if err != nil {
+			errx(1, "unable to read chunker polynomial from %s: %v", cmd.CopyChunkerParamsFrom, err)
+		}
+		cfgOpts.ChunkerPolynomial = pol
+	case cmd.ChunkerPolynomial != "":
+		cfgOpts.ChunkerPolynomial = cmd.ChunkerPolynomial
 	}
 
 	be, err := create(opts.Repo)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "creating backend at %s failed: %v\n", opts.Repo, err)
-		os.Exit(1)
+		output.Error(1, "creating backend at %s failed: %v", opts.Repo, err)
 	}
 
 	s := repository.New(be)
-	err = s.Init(pw)
+	err = s.InitWithOptions(pw, cfgOpts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "creating key in backend at %s failed: %v\n", opts.Repo, err)
-		os.Exit(1)
+		output.Error(1, "creating key in backend at %s failed: %v", opts.Repo, err)
 	}
 
-	fmt.Printf("created restic backend %v at %s\n", s.Config.ID[:10], opts.Repo)
+	output.Init(s.Config.ID[:10], opts.Repo)
 
-	fmt.Println("Please note that knowledge of your password is required to access the repository.")
-	fmt.Println("Losing your password means that your data is irrecoverably lost.")
+	output.Note("Please note that knowledge of your password is required to access the repository.")
+	output.Note("Losing your password means that your data is irrecoverably lost.")
 
 	return nil
 }
 
+// copyChunkerPolynomial opens the repository at location and returns its
+// chunker polynomial, so that a new repository can be initialized to
+// deduplicate against it. location is usually a different repository than
+// the one being created, so its password is read from passwordFile (if
+// given) or an interactive prompt, never from the --password-file/
+// --password-command/--password-stdin flags governing the new repository.
+func copyChunkerPolynomial(location, passwordFile string) (string, error) {
+	be, err := open(location)
+	if err != nil {
+		return "", err
+	}
+
+	s := repository.New(be)
+
+	var provider PasswordProvider = promptPasswordProvider{prompt: fmt.Sprintf("enter password for %s: ", location)}
+	if passwordFile != "" {
+		provider = filePasswordProvider{path: passwordFile}
+	}
+
+	pw, err := provider.Password()
+	if err != nil {
+		return "", err
+	}
+
+	if err = s.SearchKey(pw); err != nil {
+		return "", err
+	}
+
+	s.MigrateConfig()
+
+	if s.Config.ChunkerPolynomial == "" {
+		return "", fmt.Errorf("repository at %s has no stored chunker polynomial", location)
+	}
+
+	return s.Config.ChunkerPolynomial, nil
+}
+
+// CmdConfig reads and updates persistent repository configuration, such as
+// the stored compression mode.
+type CmdConfig struct {
+	Compression string `long:"compression" description:"Set the compression mode for new data (off, auto, max)"`
+}
+
+func (cmd CmdConfig) Execute(args []string) error {
+	output.JSON = opts.JSON
+
+	s, err := OpenRepo()
+	if err != nil {
+		return err
+	}
+
+	if cmd.Compression == "" {
+		output.Note("chunker-polynomial: %s", s.Config.ChunkerPolynomial)
+		output.Note("compression: %s", s.Config.Compression)
+		return nil
+	}
+
+	cfgOpts := repository.ConfigOptions{Compression: repository.CompressionMode(cmd.Compression)}
+	if err := cfgOpts.Apply(&s.Config); err != nil {
+		return err
+	}
+
+	if err := s.SaveConfig(); err != nil {
+		return err
+	}
+
+	output.Note("saved updated config")
+
+	return nil
+}
+
+// sftpArgs turns the host/user parts of a parsed sftp: URI into the
+// command-line arguments expected by backend/sftp.
+func sftpArgs(parsed *url.URL) []string {
+	args := []string{parsed.Host}
+	if parsed.User != nil && parsed.User.Username() != "" {
+		args = append(args, "-l", parsed.User.Username())
+	}
+	args = append(args, "-s", "sftp")
+	return args
+}
+
 // Open the backend specified by URI.
 // Valid formats are:
 // * /foo/bar -> local repository at /foo/bar
 // * sftp://user@host/foo/bar -> remote sftp repository on host for user at path foo/bar
 // * sftp://host//tmp/backup -> remote sftp repository on host at path /tmp/backup
+// * s3:https://s3.amazonaws.com/bucket/prefix -> repository in an S3 bucket
+//
+// The scheme determines which backend handles the URI; see backend.Register.
+// Additional schemes can be made available without changing this function.
 func open(u string) (backend.Backend, error) {
-	url, err := url.Parse(u)
+	scheme, err := backendScheme(u)
 	if err != nil {
 		return nil, err
 	}
 
-	if url.Scheme == "" {
-		return local.Open(url.Path)
-	}
-
-	args := []string{url.Host}
-	if url.User != nil && url.User.Username() != "" {
-		args = append(args, "-l")
-		args = append(args, url.User.Username())
-	}
-	args = append(args, "-s")
-	args = append(args, "sftp")
-	return sftp.Open(url.Path[1:], "ssh", args...)
+	return backend.Open(scheme, u)
 }
 
-// Create the backend specified by URI.
+// Create the backend specified by URI, see open() for the supported URI
+// formats.
 func create(u string) (backend.Backend, error) {
-	url, err := url.Parse(u)
+	scheme, err := backendScheme(u)
 	if err != nil {
 		return nil, err
 	}
 
-	if url.Scheme == "" {
-		return local.Create(url.Path)
-	}
+	return backend.Create(scheme, u)
+}
 
-	args := []string{url.Host}
-	if url.User != nil && url.User.Username() != "" {
-		args = append(args, "-l")
-		args = append(args, url.User.Username())
+// backendScheme returns the URI scheme used to select a backend from the
+// registry. A URI without a scheme (a plain path) selects the local backend.
+func backendScheme(u string) (string, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", err
 	}
-	args = append(args, "-s")
-	args = append(args, "sftp")
-	return sftp.Create(url.Path[1:], "ssh", args...)
+
+	return parsed.Scheme, nil
 }
 
 func OpenRepo() (*repository.Repository, error) {
@@ -145,11 +341,18 @@ func OpenRepo() (*repository.Repository, error) {
 
 	s := repository.New(be)
 
-	err = s.SearchKey(readPassword("RESTIC_PASSWORD", "enter password for repository: "))
+	pw, err := passwordProvider("RESTIC_PASSWORD", "enter password for repository: ").Password()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read password: %v", err)
+	}
+
+	err = s.SearchKey(pw)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open repo: %v", err)
 	}
 
+	s.MigrateConfig()
+
 	return s, nil
 }
 
@@ -164,6 +367,55 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	_, err = parser.AddCommand("config",
+		"change repository configuration",
+		"The config command reads and updates persistent repository configuration",
+		&CmdConfig{})
+	if err != nil {
+		panic(err)
+	}
+
+	// local and sftp predate the registry and take a path/host+args rather
+	// than a URI, so main.go registers them once via small adapters. New
+	// backends don't need this: they take a URI directly and call
+	// backend.Register from their own init(), as backend/s3 does. Either
+	// way, open()/create() stay a flat registry lookup with no
+	// per-scheme switch.
+	backend.Register("", openLocal, createLocal)
+	backend.Register("sftp", openSftp, createSftp)
+}
+
+func openLocal(u string) (backend.Backend, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return local.Open(parsed.Path)
+}
+
+func createLocal(u string) (backend.Backend, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return local.Create(parsed.Path)
+}
+
+func openSftp(u string) (backend.Backend, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return sftp.Open(parsed.Path[1:], "ssh", sftpArgs(parsed)...)
+}
+
+func createSftp(u string) (backend.Backend, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return sftp.Create(parsed.Path[1:], "ssh", sftpArgs(parsed)...)
 }
 
 func main() {
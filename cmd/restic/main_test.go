@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func resetPasswordOpts() {
+	opts.PasswordFile = ""
+	opts.PasswordCommand = ""
+	opts.PasswordStdin = false
+}
+
+func TestPasswordProviderPriority(t *testing.T) {
+	defer resetPasswordOpts()
+	resetPasswordOpts()
+
+	if _, ok := passwordProvider("RESTIC_PASSWORD", "prompt: ").(promptPasswordProvider); !ok {
+		t.Fatal("expected promptPasswordProvider when no password flag is set")
+	}
+
+	opts.PasswordStdin = true
+	if _, ok := passwordProvider("RESTIC_PASSWORD", "prompt: ").(stdinPasswordProvider); !ok {
+		t.Fatal("expected stdinPasswordProvider when --password-stdin is set")
+	}
+
+	opts.PasswordCommand = "echo hi"
+	if _, ok := passwordProvider("RESTIC_PASSWORD", "prompt: ").(commandPasswordProvider); !ok {
+		t.Fatal("expected commandPasswordProvider to take priority over --password-stdin")
+	}
+
+	opts.PasswordFile = "/some/file"
+	if _, ok := passwordProvider("RESTIC_PASSWORD", "prompt: ").(filePasswordProvider); !ok {
+		t.Fatal("expected filePasswordProvider to take priority over --password-command")
+	}
+}
+
+func TestFilePasswordProvider(t *testing.T) {
+	f, err := ioutil.TempFile("", "restic-password-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("hunter2\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	pw, err := (filePasswordProvider{path: f.Name()}).Password()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pw != "hunter2" {
+		t.Errorf("Password() = %q, want %q", pw, "hunter2")
+	}
+}
+
+func TestFilePasswordProviderMissingFile(t *testing.T) {
+	if _, err := (filePasswordProvider{path: "/nonexistent/path"}).Password(); err == nil {
+		t.Fatal("expected an error for a missing password file")
+	}
+}
+
+func TestCommandPasswordProvider(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	pw, err := (commandPasswordProvider{cmd: "printf 'hunter2\\nsecond line\\n'"}).Password()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pw != "hunter2" {
+		t.Errorf("Password() = %q, want only the first line %q", pw, "hunter2")
+	}
+}
+
+func TestStdinPasswordProvider(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = origStdin }()
+
+	if _, err := w.WriteString("hunter2\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	pw, err := (stdinPasswordProvider{}).Password()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pw != "hunter2" {
+		t.Errorf("Password() = %q, want %q", pw, "hunter2")
+	}
+}
+
+func TestStdinPasswordProviderEOF(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	origStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = origStdin }()
+
+	if _, err := (stdinPasswordProvider{}).Password(); err == nil {
+		t.Fatal("expected an error when stdin is closed without any input")
+	}
+}
@@ -0,0 +1,72 @@
+// Package output prints restic's status and error messages, either as
+// human-readable text or, when JSON is set, as newline-delimited JSON
+// objects so that wrapping programs can parse them reliably.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// JSON selects machine-readable output. It is set once at startup from the
+// --json flag.
+var JSON bool
+
+type statusMessage struct {
+	MessageType  string `json:"message_type"`
+	Action       string `json:"action"`
+	RepositoryID string `json:"repository_id,omitempty"`
+}
+
+type errorMessage struct {
+	MessageType string `json:"message_type"`
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+}
+
+// Init reports that a new repository was created at location with the
+// given repository ID.
+func Init(repositoryID, location string) {
+	if JSON {
+		json.NewEncoder(os.Stdout).Encode(statusMessage{
+			MessageType:  "status",
+			Action:       "init",
+			RepositoryID: repositoryID,
+		})
+		return
+	}
+
+	fmt.Printf("created restic backend %v at %s\n", repositoryID, location)
+}
+
+// Note prints a human-readable informational message. It is suppressed in
+// JSON mode, where such messages carry no structured meaning.
+func Note(format string, data ...interface{}) {
+	if JSON {
+		return
+	}
+
+	fmt.Printf(format+"\n", data...)
+}
+
+// Error prints a fatal error message, in JSON mode as a structured error
+// object on stderr, and exits the process with the given code.
+func Error(code int, format string, data ...interface{}) {
+	msg := fmt.Sprintf(format, data...)
+
+	if JSON {
+		json.NewEncoder(os.Stderr).Encode(errorMessage{
+			MessageType: "error",
+			Code:        code,
+			Message:     msg,
+		})
+		os.Exit(code)
+	}
+
+	if len(msg) > 0 && msg[len(msg)-1] != '\n' {
+		msg += "\n"
+	}
+	fmt.Fprint(os.Stderr, msg)
+	os.Exit(code)
+}
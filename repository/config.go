@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/restic/chunker"
+)
+
+// CompressionMode is the compression setting recorded in a repository's
+// config. It is only stored and reported; data written to the repository is
+// not compressed in any mode.
+type CompressionMode string
+
+// Supported compression modes.
+const (
+	CompressionOff  CompressionMode = "off"
+	CompressionAuto CompressionMode = "auto"
+	CompressionMax  CompressionMode = "max"
+)
+
+func (m CompressionMode) valid() bool {
+	switch m {
+	case "", CompressionOff, CompressionAuto, CompressionMax:
+		return true
+	default:
+		return false
+	}
+}
+
+// validChunkerPolynomial reports whether s is empty (meaning "leave
+// unchanged") or a polynomial chunker.ParsePol accepts. Validating here
+// catches a bad --chunker-polynomial at init/config time instead of letting
+// it fail deep inside the chunker later.
+func validChunkerPolynomial(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	_, err := chunker.ParsePol(s)
+	return err == nil
+}
+
+// Config describes a repository's persistent configuration. It is created
+// once by Init and read back by SearchKey.
+type Config struct {
+	Version           uint            `json:"version"`
+	ID                string          `json:"id"`
+	ChunkerPolynomial string          `json:"chunker_polynomial,omitempty"`
+	Compression       CompressionMode `json:"compression,omitempty"`
+}
+
+// migrate fills in defaults for fields that didn't exist in older config
+// versions, so that repositories created before this field was added keep
+// working without a forced re-init.
+func (cfg *Config) migrate() {
+	if cfg.Compression == "" {
+		cfg.Compression = CompressionOff
+	}
+}
+
+// ConfigOptions bundles the chunker and compression parameters that can be
+// set when a repository is created or reconfigured. Sharing the chunker
+// polynomial across repositories keeps deduplication working when copying
+// snapshots between them; compression is opt-in per repository.
+type ConfigOptions struct {
+	ChunkerPolynomial string
+	Compression       CompressionMode
+}
+
+// Apply validates opts and writes them into cfg. Fields left at their zero
+// value are left unchanged.
+func (opts ConfigOptions) Apply(cfg *Config) error {
+	if !opts.Compression.valid() {
+		return fmt.Errorf("invalid compression mode %q", opts.Compression)
+	}
+
+	if !validChunkerPolynomial(opts.ChunkerPolynomial) {
+		return fmt.Errorf("invalid chunker polynomial %q", opts.ChunkerPolynomial)
+	}
+
+	if opts.ChunkerPolynomial != "" {
+		cfg.ChunkerPolynomial = opts.ChunkerPolynomial
+	}
+
+	if opts.Compression != "" {
+		cfg.Compression = opts.Compression
+	}
+
+	return nil
+}
+
+// InitWithOptions creates a new repository like Init, additionally applying
+// opts to the repository's config before it is persisted. opts is validated
+// before Init runs, so an invalid flag fails before the (hard to reverse)
+// repository creation, rather than leaving a half-configured repository
+// behind.
+func (r *Repository) InitWithOptions(password string, opts ConfigOptions) error {
+	if !opts.Compression.valid() {
+		return fmt.Errorf("invalid compression mode %q", opts.Compression)
+	}
+
+	if !validChunkerPolynomial(opts.ChunkerPolynomial) {
+		return fmt.Errorf("invalid chunker polynomial %q", opts.ChunkerPolynomial)
+	}
+
+	if err := r.Init(password); err != nil {
+		return err
+	}
+
+	if err := opts.Apply(&r.Config); err != nil {
+		return err
+	}
+
+	r.Config.migrate()
+
+	return r.SaveConfig()
+}
+
+// MigrateConfig fills in defaults for config fields that didn't exist when
+// this repository was initialized. Call it after loading a repository's
+// config from the backend (e.g. via SearchKey), so repositories created
+// before a field was added behave as if it had always been set.
+func (r *Repository) MigrateConfig() {
+	r.Config.migrate()
+}
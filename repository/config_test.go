@@ -0,0 +1,80 @@
+package repository
+
+import "testing"
+
+func TestCompressionModeValid(t *testing.T) {
+	for _, m := range []CompressionMode{"", CompressionOff, CompressionAuto, CompressionMax} {
+		if !m.valid() {
+			t.Errorf("CompressionMode(%q).valid() = false, want true", m)
+		}
+	}
+
+	if CompressionMode("bogus").valid() {
+		t.Error(`CompressionMode("bogus").valid() = true, want false`)
+	}
+}
+
+func TestValidChunkerPolynomial(t *testing.T) {
+	if !validChunkerPolynomial("") {
+		t.Error(`validChunkerPolynomial("") = false, want true`)
+	}
+
+	if validChunkerPolynomial("not-a-polynomial") {
+		t.Error(`validChunkerPolynomial("not-a-polynomial") = true, want false`)
+	}
+}
+
+func TestConfigOptionsApplyLeavesZeroValuesUnchanged(t *testing.T) {
+	cfg := Config{ChunkerPolynomial: "3ddb", Compression: CompressionOff}
+
+	if err := (ConfigOptions{}).Apply(&cfg); err != nil {
+		t.Fatalf("Apply with zero-value options returned an error: %v", err)
+	}
+
+	if cfg.ChunkerPolynomial != "3ddb" || cfg.Compression != CompressionOff {
+		t.Errorf("Apply with zero-value options changed cfg: %+v", cfg)
+	}
+}
+
+func TestConfigOptionsApplyMerges(t *testing.T) {
+	cfg := Config{Compression: CompressionOff}
+
+	err := ConfigOptions{Compression: CompressionMax}.Apply(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Compression != CompressionMax {
+		t.Errorf("Compression = %q, want %q", cfg.Compression, CompressionMax)
+	}
+}
+
+func TestConfigOptionsApplyInvalidCompression(t *testing.T) {
+	if err := (ConfigOptions{Compression: "bogus"}).Apply(&Config{}); err == nil {
+		t.Fatal("expected an error for an invalid compression mode")
+	}
+}
+
+func TestConfigOptionsApplyInvalidChunkerPolynomial(t *testing.T) {
+	if err := (ConfigOptions{ChunkerPolynomial: "not-a-polynomial"}).Apply(&Config{}); err == nil {
+		t.Fatal("expected an error for an invalid chunker polynomial")
+	}
+}
+
+func TestMigrateSetsCompressionDefault(t *testing.T) {
+	cfg := Config{}
+	cfg.migrate()
+
+	if cfg.Compression != CompressionOff {
+		t.Errorf("migrate() left Compression = %q, want %q", cfg.Compression, CompressionOff)
+	}
+}
+
+func TestMigrateLeavesSetCompressionAlone(t *testing.T) {
+	cfg := Config{Compression: CompressionMax}
+	cfg.migrate()
+
+	if cfg.Compression != CompressionMax {
+		t.Errorf("migrate() changed an already-set Compression to %q", cfg.Compression)
+	}
+}